@@ -0,0 +1,68 @@
+package oggreader
+
+// ChecksumError is returned (or, under ValidationModeWarn, reported to
+// the configured handler) when a page's stored checksum does not match
+// the checksum computed over its contents.
+type ChecksumError struct {
+	Expected  uint32
+	Got       uint32
+	PageIndex uint32
+}
+
+func (e *ChecksumError) Error() string {
+	return "oggreader: checksum mismatch on page " +
+		itoa(e.PageIndex) + ": expected " + itoa(e.Expected) + " got " + itoa(e.Got)
+}
+
+// itoa avoids pulling in strconv for a single error-formatting helper.
+func itoa(v uint32) string {
+	if v == 0 {
+		return "0"
+	}
+
+	var buf [10]byte
+	i := len(buf)
+	for v > 0 {
+		i--
+		buf[i] = byte('0' + v%10)
+		v /= 10
+	}
+
+	return string(buf[i:])
+}
+
+// crc32Table is the lookup table for the Ogg-specific CRC-32: polynomial
+// 0x04c11db7, non-reflected, initial value 0, no final XOR.
+//
+// https://tools.ietf.org/html/rfc3533.html#section-5
+var crc32Table = generateCRC32Table()
+
+func generateCRC32Table() [256]uint32 {
+	const polynomial = 0x04c11db7
+
+	var table [256]uint32
+	for i := range table {
+		r := uint32(i) << 24
+		for bit := 0; bit < 8; bit++ {
+			if r&0x80000000 != 0 {
+				r = (r << 1) ^ polynomial
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+
+	return table
+}
+
+// oggCRC32 computes the Ogg-specific CRC-32 over page, which must have
+// the 4 checksum bytes at offset 22 already zeroed out.
+func oggCRC32(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = (crc << 8) ^ crc32Table[byte(crc>>24)^b]
+	}
+
+	return crc
+}
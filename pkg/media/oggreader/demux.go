@@ -0,0 +1,129 @@
+package oggreader
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// LogicalStream tracks the header state, comment metadata, and
+// undelivered data pages for one Ogg logical bitstream (identified by
+// its page serial number) within a multiplexed or chained physical
+// stream.
+//
+// https://tools.ietf.org/html/rfc3533.html#section-4
+type LogicalStream struct {
+	Serial uint32
+
+	// Header is the parsed OpusHead ID header, or nil if this logical
+	// stream's BOS page payload was not recognized as one (e.g. a
+	// Skeleton or Theora track multiplexed alongside Opus).
+	Header *OggHeader
+
+	// CommentHeader is the parsed OpusTags comment header, populated
+	// once this logical stream's second page has been demultiplexed.
+	CommentHeader *OggCommentHeader
+
+	// GranulePosition is the granule position of the most recently
+	// demultiplexed data page for this stream.
+	GranulePosition uint64
+
+	ended          bool
+	sawCommentPage bool
+
+	pages       [][]byte
+	pageHeaders []*OggPageHeader
+}
+
+// Ended reports whether an EOS page has been seen for this logical
+// stream.
+func (s *LogicalStream) Ended() bool {
+	return s.ended
+}
+
+// Streams returns every logical stream discovered so far, in the order
+// their BOS pages were first seen. Demultiplexing is driven by NewWith's
+// header reads and by calls to ParseNextPageForStream, so a serial whose
+// BOS page has not been read yet does not appear here even if its pages
+// are already present in the underlying stream.
+func (o *OggReader) Streams() []*LogicalStream {
+	streams := make([]*LogicalStream, len(o.streamOrder))
+	for i, serial := range o.streamOrder {
+		streams[i] = o.streams[serial]
+	}
+
+	return streams
+}
+
+// ParseNextPageForStream returns the next undelivered data page payload
+// and header belonging to the logical stream with the given serial
+// number, demultiplexing further pages from the underlying stream as
+// needed. It returns an error once the underlying stream is exhausted,
+// even if other logical streams still have undelivered pages.
+func (o *OggReader) ParseNextPageForStream(serial uint32) ([]byte, *OggPageHeader, error) {
+	for {
+		if stream, ok := o.streams[serial]; ok && len(stream.pages) > 0 {
+			payload := stream.pages[0]
+			pageHeader := stream.pageHeaders[0]
+			stream.pages = stream.pages[1:]
+			stream.pageHeaders = stream.pageHeaders[1:]
+
+			return payload, pageHeader, nil
+		}
+
+		if err := o.demuxNextPage(); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// demuxNextPage reads a single page from the underlying stream and
+// files it onto the logical stream identified by its serial number: a
+// BOS page starts a new logical stream, the next page with the same
+// serial after its BOS page is parsed as the OpusTags comment header,
+// and every other page is queued as data for later retrieval.
+func (o *OggReader) demuxNextPage() error {
+	segments, pageHeader, err := o.readPageSegments()
+	if err != nil {
+		return err
+	}
+	payload := bytes.Join(segments, nil)
+
+	if o.streams == nil {
+		o.streams = map[uint32]*LogicalStream{}
+	}
+
+	stream, ok := o.streams[pageHeader.serial]
+	if !ok {
+		if pageHeader.headerType&pageHeaderTypeBeginningOfStream == 0 {
+			return fmt.Errorf("page for unknown serial %d is not a beginning-of-stream page", pageHeader.serial)
+		}
+
+		stream = &LogicalStream{Serial: pageHeader.serial}
+		if header, headerErr := parseIDHeader(payload); headerErr == nil {
+			stream.Header = header
+		}
+
+		o.streams[pageHeader.serial] = stream
+		o.streamOrder = append(o.streamOrder, pageHeader.serial)
+
+		return nil
+	}
+
+	if !stream.sawCommentPage {
+		stream.sawCommentPage = true
+		if commentHeader, commentErr := parseCommentHeader(payload); commentErr == nil {
+			stream.CommentHeader = commentHeader
+		}
+		return nil
+	}
+
+	stream.GranulePosition = pageHeader.granulePos
+	stream.pages = append(stream.pages, payload)
+	stream.pageHeaders = append(stream.pageHeaders, pageHeader)
+
+	if pageHeader.headerType&pageHeaderTypeEndOfStream != 0 {
+		stream.ended = true
+	}
+
+	return nil
+}
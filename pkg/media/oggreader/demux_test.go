@@ -0,0 +1,128 @@
+package oggreader
+
+import (
+	"bytes"
+	"testing"
+)
+
+func multiplexedOggFile() []byte {
+	var buf bytes.Buffer
+	buf.Write(buildPage(pageHeaderTypeBeginningOfStream, 0, 111, 0, idHeaderPayload()))
+	buf.Write(buildPage(pageHeaderTypeBeginningOfStream, 0, 222, 0, idHeaderPayload()))
+	buf.Write(buildPage(0x00, 0, 111, 1, commentHeaderPayload("vendor-a")))
+	buf.Write(buildPage(0x00, 0, 222, 1, commentHeaderPayload("vendor-b")))
+	buf.Write(buildPage(0x00, 960, 222, 2, []byte{0x02}))
+	buf.Write(buildPage(0x00, 960, 111, 2, []byte{0x01}))
+	buf.Write(buildPage(pageHeaderTypeEndOfStream, 1920, 111, 3, []byte{0x03}))
+	buf.Write(buildPage(pageHeaderTypeEndOfStream, 1920, 222, 3, []byte{0x04}))
+	return buf.Bytes()
+}
+
+func TestOggReader_ParseNextPageForStream_Interleaves(t *testing.T) {
+	reader := &OggReader{stream: bytes.NewReader(multiplexedOggFile())}
+
+	payload, pageHeader, err := reader.ParseNextPageForStream(111)
+	if err != nil {
+		t.Fatalf("ParseNextPageForStream(111) returned error: %v", err)
+	}
+	if !bytes.Equal(payload, []byte{0x01}) {
+		t.Errorf("unexpected first payload for stream 111: %v", payload)
+	}
+	if pageHeader.granulePos != 960 {
+		t.Errorf("unexpected granulePos: %d", pageHeader.granulePos)
+	}
+
+	// Stream 222's data page was already read off the underlying stream
+	// while looking for stream 111's; it must still be retrievable, not
+	// dropped.
+	payload, _, err = reader.ParseNextPageForStream(222)
+	if err != nil {
+		t.Fatalf("ParseNextPageForStream(222) returned error: %v", err)
+	}
+	if !bytes.Equal(payload, []byte{0x02}) {
+		t.Errorf("unexpected first payload for stream 222: %v", payload)
+	}
+
+	payload, _, err = reader.ParseNextPageForStream(111)
+	if err != nil {
+		t.Fatalf("ParseNextPageForStream(111) returned error: %v", err)
+	}
+	if !bytes.Equal(payload, []byte{0x03}) {
+		t.Errorf("unexpected second payload for stream 111: %v", payload)
+	}
+
+	payload, _, err = reader.ParseNextPageForStream(222)
+	if err != nil {
+		t.Fatalf("ParseNextPageForStream(222) returned error: %v", err)
+	}
+	if !bytes.Equal(payload, []byte{0x04}) {
+		t.Errorf("unexpected second payload for stream 222: %v", payload)
+	}
+
+	if _, _, err = reader.ParseNextPageForStream(111); err == nil {
+		t.Error("expected an error once the underlying stream is exhausted")
+	}
+
+	streams := reader.Streams()
+	if len(streams) != 2 {
+		t.Fatalf("expected 2 logical streams, got %d", len(streams))
+	}
+	for _, stream := range streams {
+		if stream.Header == nil {
+			t.Errorf("expected an ID header for stream %d", stream.Serial)
+		}
+		if stream.CommentHeader == nil {
+			t.Errorf("expected a comment header for stream %d", stream.Serial)
+		}
+		if stream.GranulePosition != 1920 {
+			t.Errorf("expected final granulePos 1920 for stream %d, got %d", stream.Serial, stream.GranulePosition)
+		}
+		if !stream.Ended() {
+			t.Errorf("expected stream %d to be marked ended", stream.Serial)
+		}
+	}
+}
+
+func TestOggReader_Streams_EmptyBeforeDemuxing(t *testing.T) {
+	reader := &OggReader{stream: bytes.NewReader(multiplexedOggFile())}
+	if streams := reader.Streams(); len(streams) != 0 {
+		t.Errorf("expected no streams before any demuxing reads, got %d", len(streams))
+	}
+}
+
+func TestOggReader_NewWith_MultiplexedFile(t *testing.T) {
+	reader, header, commentHeader, err := NewWith(bytes.NewReader(multiplexedOggFile()))
+	if err != nil {
+		t.Fatalf("NewWith returned error on a multiplexed (two leading BOS pages) file: %v", err)
+	}
+	if header.Channels != 2 {
+		t.Errorf("expected 2 channels, got %d", header.Channels)
+	}
+	if commentHeader.Vendor != "vendor-a" {
+		t.Errorf("expected vendor-a, got %q", commentHeader.Vendor)
+	}
+
+	// NewWith only needs to consume enough pages to resolve stream 111's
+	// ID and comment headers, but stream 222's BOS page sits between
+	// them and gets registered as a side effect.
+	streams := reader.Streams()
+	if len(streams) != 2 || streams[0].Serial != 111 || streams[1].Serial != 222 {
+		t.Fatalf("expected streams [111, 222] to be registered by NewWith, got %v", streams)
+	}
+
+	payload, _, err := reader.ParseNextPageForStream(111)
+	if err != nil {
+		t.Fatalf("ParseNextPageForStream(111) returned error: %v", err)
+	}
+	if !bytes.Equal(payload, []byte{0x01}) {
+		t.Errorf("unexpected first payload for stream 111: %v", payload)
+	}
+
+	payload, _, err = reader.ParseNextPageForStream(222)
+	if err != nil {
+		t.Fatalf("ParseNextPageForStream(222) returned error: %v", err)
+	}
+	if !bytes.Equal(payload, []byte{0x02}) {
+		t.Errorf("unexpected first payload for stream 222: %v", payload)
+	}
+}
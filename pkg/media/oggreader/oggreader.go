@@ -6,21 +6,49 @@ import (
 	"encoding/binary"
 	"fmt"
 	"io"
+	"strings"
 )
 
 const (
-	pageHeaderTypeBeginningOfStream = 0x02
-	idPageSignature                 = "OpusHead"
-	commentPageSignature            = "OpusTags"
-	pageHeaderSignature             = "OggS"
+	pageHeaderTypeContinuationOfStream = 0x01
+	pageHeaderTypeBeginningOfStream    = 0x02
+	pageHeaderTypeEndOfStream          = 0x04
+	idPageSignature                    = "OpusHead"
+	commentPageSignature               = "OpusTags"
+	pageHeaderSignature                = "OggS"
 
-	pageHeaderLen = 28
+	// pageHeaderLen is the length of the fixed portion of an Ogg page
+	// header, i.e. everything up to and including the segment count.
+	// The segment table itself is variable length (segmentsCount bytes)
+	// and is read separately.
+	pageHeaderLen = 27
+
+	maxSegmentSize = 255
 )
 
 // OggReader is used to read Ogg files and return page payloads
 type OggReader struct {
 	stream               io.ReadSeeker
 	bytesReadSuccesfully int64
+
+	// segments holds the lacing-delimited segments of the current page
+	// that have not yet been consumed by ParseNextPacket, and pending
+	// holds the bytes of a packet that is continued across a page
+	// boundary (the previous page's final segment was 255 bytes long).
+	segments [][]byte
+	pending  []byte
+
+	validationMode       ValidationMode
+	checksumErrorHandler func(*ChecksumError)
+
+	resyncOnInvalidSignature bool
+	maxResyncWindow          int
+
+	// streams and streamOrder back Streams and ParseNextPageForStream;
+	// they are populated lazily, as pages are demultiplexed by NewWith's
+	// header reads and by ParseNextPageForStream.
+	streams     map[uint32]*LogicalStream
+	streamOrder []uint32
 }
 
 // OggHeader is the metadata from the first two pages
@@ -52,43 +80,62 @@ type OggPageHeader struct {
 	checksum uint32
 }
 
-// NewWith returns a new Ogg reader and Ogg header
-// with an io.ReadSeeker input
-func NewWith(in io.ReadSeeker) (*OggReader, *OggHeader, error) {
+// NewWith returns a new Ogg reader, its ID header, and its comment
+// header, with an io.ReadSeeker input
+func NewWith(in io.ReadSeeker, opts ...Option) (*OggReader, *OggHeader, *OggCommentHeader, error) {
 	if in == nil {
-		return nil, nil, fmt.Errorf("stream is nil")
+		return nil, nil, nil, fmt.Errorf("stream is nil")
 	}
 
 	reader := &OggReader{
-		stream: in,
+		stream:         in,
+		validationMode: ValidationModeSkip,
+	}
+
+	for _, opt := range opts {
+		opt(reader)
 	}
 
-	header, err := reader.readHeaders()
+	header, commentHeader, err := reader.readHeaders()
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, nil, err
 	}
 
-	return reader, header, nil
+	return reader, header, commentHeader, nil
 }
 
-// read
-func (o *OggReader) readHeaders() (*OggHeader, error) {
-	payload, pageHeader, err := o.ParseNextPage()
-	if err != nil {
-		return nil, err
+// readHeaders demultiplexes the first logical stream's BOS and comment
+// pages via demuxNextPage, so that the stream NewWith's caller gets back
+// is registered in o.streams/o.streamOrder exactly like any other
+// logical stream discovered through Streams/ParseNextPageForStream.
+func (o *OggReader) readHeaders() (*OggHeader, *OggCommentHeader, error) {
+	if err := o.demuxNextPage(); err != nil {
+		return nil, nil, err
 	}
 
-	header := &OggHeader{}
-	if string(pageHeader.sig[:]) != pageHeaderSignature {
-		return nil, fmt.Errorf("bad header signature")
+	serial := o.streamOrder[0]
+	stream := o.streams[serial]
+	if stream.Header == nil {
+		return nil, nil, fmt.Errorf("bad header signature")
 	}
 
-	if pageHeader.headerType != pageHeaderTypeBeginningOfStream {
-		return nil, fmt.Errorf("wrong header, expected beginning of stream")
+	for stream.CommentHeader == nil {
+		if stream.sawCommentPage {
+			return nil, nil, fmt.Errorf("bad comment header")
+		}
+
+		if err := o.demuxNextPage(); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	// TODO make sure payload is big enough
-	if len(payload) == 0 {
+	return stream.Header, stream.CommentHeader, nil
+}
+
+// parseIDHeader parses the payload of an ID (OpusHead) page per RFC
+// 7845 section 5.1.
+func parseIDHeader(payload []byte) (*OggHeader, error) {
+	if len(payload) < 19 {
 		return nil, fmt.Errorf("bad header size")
 	}
 
@@ -97,6 +144,7 @@ func (o *OggReader) readHeaders() (*OggHeader, error) {
 		return nil, fmt.Errorf("wrong signature: %s", s)
 	}
 
+	header := &OggHeader{}
 	header.Version = payload[8]
 	header.Channels = payload[9]
 	header.PreSkip = binary.LittleEndian.Uint16(payload[10:12])
@@ -104,28 +152,154 @@ func (o *OggReader) readHeaders() (*OggHeader, error) {
 	header.OutputGain = binary.LittleEndian.Uint16(payload[16:18])
 	header.ChannelMap = payload[18]
 
-	// read and skip comment header pages
-	for {
-		commentPayload, _, err := o.ParseNextPage()
+	return header, nil
+}
+
+// OggCommentHeader is the metadata from the comment (OpusTags) header,
+// the second page of an Ogg Opus stream.
+//
+// https://tools.ietf.org/html/rfc7845.html#section-5.2
+type OggCommentHeader struct {
+	Vendor string
+	// Comments maps an upper-cased Vorbis comment key (e.g. "TITLE",
+	// "ARTIST") to all of its values, in the order they appeared.
+	Comments map[string][]string
+}
+
+// parseCommentHeader parses the payload of a comment (OpusTags) page
+// per RFC 7845 section 5.2: an 8-byte "OpusTags" magic, a 4-byte LE
+// vendor string length + vendor string, a 4-byte LE comment count, and
+// for each comment a 4-byte LE length + "KEY=VALUE" string.
+func parseCommentHeader(payload []byte) (*OggCommentHeader, error) {
+	if len(payload) < 8 || string(payload[:8]) != commentPageSignature {
+		return nil, fmt.Errorf("wrong signature: %s", commentPageSignature)
+	}
+
+	offset := 8
+	vendor, offset, err := readLengthPrefixedString(payload, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload) < offset+4 {
+		return nil, fmt.Errorf("bad comment header size")
+	}
+	commentCount := int(binary.LittleEndian.Uint32(payload[offset : offset+4]))
+	offset += 4
+
+	comments := make(map[string][]string)
+	for i := 0; i < commentCount; i++ {
+		var comment string
+		comment, offset, err = readLengthPrefixedString(payload, offset)
 		if err != nil {
 			return nil, err
 		}
 
-		// If page was not a header rewind
-		if !bytes.Contains(commentPayload, []byte(commentPageSignature)) {
-			if _, err = o.stream.Seek(-1*int64(pageHeaderLen+len(commentPayload)), io.SeekCurrent); err != nil {
-				return nil, err
-			}
-			break
+		key, value, ok := splitComment(comment)
+		if !ok {
+			// Not a well-formed KEY=VALUE comment, skip it.
+			continue
 		}
+		key = strings.ToUpper(key)
+		comments[key] = append(comments[key], value)
 	}
 
-	return header, nil
+	return &OggCommentHeader{Vendor: vendor, Comments: comments}, nil
 }
 
-// ParseNextPage reads from stream and returns Ogg page payload, header,
-// and an error if there is incomplete page data.
+// readLengthPrefixedString reads a 4-byte little-endian length followed
+// by that many bytes of UTF-8 string data, starting at offset, and
+// returns the string and the offset immediately following it.
+func readLengthPrefixedString(payload []byte, offset int) (string, int, error) {
+	if len(payload) < offset+4 {
+		return "", 0, fmt.Errorf("bad comment header size")
+	}
+	length := int(binary.LittleEndian.Uint32(payload[offset : offset+4]))
+	offset += 4
+
+	if length < 0 || len(payload) < offset+length {
+		return "", 0, fmt.Errorf("bad comment header size")
+	}
+
+	return string(payload[offset : offset+length]), offset + length, nil
+}
+
+// splitComment splits a Vorbis "KEY=VALUE" comment string on its first
+// '=' byte.
+func splitComment(comment string) (key, value string, ok bool) {
+	idx := strings.IndexByte(comment, '=')
+	if idx < 0 {
+		return "", "", false
+	}
+
+	return comment[:idx], comment[idx+1:], true
+}
+
+// ParseNextPage reads from stream and returns the full Ogg page payload
+// (all segments of the page concatenated), its header, and an error if
+// there is incomplete page data.
+//
+// A single Ogg page may contain more than one logical packet, and a
+// logical packet may span more than one page. Callers that need packet
+// boundaries rather than raw page payloads should use ParseNextPacket
+// instead.
 func (o *OggReader) ParseNextPage() ([]byte, *OggPageHeader, error) {
+	segments, pageHeader, err := o.readPageSegments()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload := bytes.Join(segments, nil)
+	return payload, pageHeader, nil
+}
+
+// ParseNextPacket reads from stream and returns the next complete
+// logical packet, reassembling it across page boundaries when the
+// packet was split into 255-byte terminal segments.
+//
+// https://tools.ietf.org/html/rfc3533.html#section-4
+func (o *OggReader) ParseNextPacket() ([]byte, *OggPageHeader, error) {
+	var lastHeader *OggPageHeader
+
+	for {
+		if len(o.segments) == 0 {
+			segments, pageHeader, err := o.readPageSegments()
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if len(o.pending) > 0 && pageHeader.headerType&pageHeaderTypeContinuationOfStream == 0 {
+				return nil, nil, fmt.Errorf("page claims to not be a continuation, but a packet is in progress")
+			}
+
+			if len(segments) == 0 {
+				// A legal but empty page (segmentsCount == 0, e.g. a
+				// keepalive); nothing to reassemble, read the next page.
+				continue
+			}
+
+			o.segments = segments
+			lastHeader = pageHeader
+		}
+
+		segment := o.segments[0]
+		o.segments = o.segments[1:]
+		o.pending = append(o.pending, segment...)
+
+		if len(segment) < maxSegmentSize {
+			packet := o.pending
+			o.pending = nil
+			return packet, lastHeader, nil
+		}
+
+		// segment is exactly maxSegmentSize bytes long, so the packet
+		// continues in the next segment (possibly on the next page).
+	}
+}
+
+// readPageSegments reads a single Ogg page from the stream and splits
+// its payload into the segments described by the page's lacing table.
+func (o *OggReader) readPageSegments() ([][]byte, *OggPageHeader, error) {
 	h := make([]byte, pageHeaderLen)
 
 	n, err := o.stream.Read(h)
@@ -135,6 +309,24 @@ func (o *OggReader) ParseNextPage() ([]byte, *OggPageHeader, error) {
 		return nil, nil, fmt.Errorf("header len mismatch")
 	}
 
+	if string(h[0:4]) != pageHeaderSignature {
+		if !o.resyncOnInvalidSignature {
+			return nil, nil, fmt.Errorf("bad header signature")
+		}
+
+		if _, err = o.stream.Seek(-int64(len(h)), io.SeekCurrent); err != nil {
+			return nil, nil, err
+		}
+
+		skipped, err := o.Resync()
+		if err != nil {
+			return nil, nil, err
+		}
+		o.bytesReadSuccesfully += skipped
+
+		return o.readPageSegments()
+	}
+
 	pageHeader := &OggPageHeader{
 		sig: [4]byte{h[0], h[1], h[2], h[3]},
 	}
@@ -144,22 +336,71 @@ func (o *OggReader) ParseNextPage() ([]byte, *OggPageHeader, error) {
 	pageHeader.granulePos = binary.LittleEndian.Uint64(h[6 : 6+8])
 	pageHeader.serial = binary.LittleEndian.Uint32(h[14 : 14+4])
 	pageHeader.index = binary.LittleEndian.Uint32(h[18 : 18+4])
+	pageHeader.checksum = binary.LittleEndian.Uint32(h[22 : 22+4])
 	pageHeader.segmentsCount = h[26]
 
-	payloadSize := h[27]
-	payload := []byte{}
+	lacingTable := make([]byte, pageHeader.segmentsCount)
+	if pageHeader.segmentsCount > 0 {
+		if _, err = io.ReadFull(o.stream, lacingTable); err != nil {
+			return nil, nil, err
+		}
+	}
 
-	if payloadSize > 0 {
-		payload = make([]byte, payloadSize)
+	segments := make([][]byte, 0, len(lacingTable))
+	for _, segmentSize := range lacingTable {
+		segment := make([]byte, segmentSize)
+		if segmentSize > 0 {
+			if _, err = io.ReadFull(o.stream, segment); err != nil {
+				return nil, nil, err
+			}
+		}
+		segments = append(segments, segment)
+	}
 
-		if _, err = o.stream.Read(payload); err != nil {
+	if o.validationMode != ValidationModeSkip {
+		if err = o.validateChecksum(h, lacingTable, segments, pageHeader); err != nil {
 			return nil, nil, err
 		}
+	}
+
+	return segments, pageHeader, nil
+}
+
+// validateChecksum recomputes the Ogg CRC-32 over a page's header (with
+// the stored checksum bytes zeroed), lacing table, and payload, and
+// compares it against the checksum recorded in the header.
+func (o *OggReader) validateChecksum(h, lacingTable []byte, segments [][]byte, pageHeader *OggPageHeader) error {
+	zeroed := make([]byte, len(h))
+	copy(zeroed, h)
+	zeroed[22], zeroed[23], zeroed[24], zeroed[25] = 0, 0, 0, 0
 
-		pageHeader.checksum = binary.LittleEndian.Uint32(h[22 : 22+4])
+	raw := make([]byte, 0, len(zeroed)+len(lacingTable)+int(pageHeader.segmentsCount)*maxSegmentSize)
+	raw = append(raw, zeroed...)
+	raw = append(raw, lacingTable...)
+	for _, segment := range segments {
+		raw = append(raw, segment...)
 	}
 
-	return payload, pageHeader, nil
+	computed := oggCRC32(raw)
+	if computed == pageHeader.checksum {
+		return nil
+	}
+
+	checksumErr := &ChecksumError{
+		Expected:  pageHeader.checksum,
+		Got:       computed,
+		PageIndex: pageHeader.index,
+	}
+
+	switch o.validationMode {
+	case ValidationModeWarn:
+		if o.checksumErrorHandler != nil {
+			o.checksumErrorHandler(checksumErr)
+		}
+		return nil
+	default:
+		return checksumErr
+	}
 }
 
 // ResetReader resets the internal stream of OggReader. This is useful
@@ -0,0 +1,344 @@
+package oggreader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// buildPage assembles a single Ogg page. Each element of segments is a
+// single lacing-table entry (0-255 bytes); callers that need a segment
+// of exactly maxSegmentSize bytes to signal packet continuation should
+// pass it as its own element.
+func buildPage(headerType byte, granulePos uint64, serial, index uint32, segments ...[]byte) []byte {
+	var lacing []byte
+	var payload []byte
+
+	for _, segment := range segments {
+		if len(segment) > maxSegmentSize {
+			panic("test segment exceeds maxSegmentSize")
+		}
+		lacing = append(lacing, byte(len(segment)))
+		payload = append(payload, segment...)
+	}
+
+	page := []byte{'O', 'g', 'g', 'S', 0x00, headerType}
+	granuleBytes := make([]byte, 8)
+	for i := 0; i < 8; i++ {
+		granuleBytes[i] = byte(granulePos >> (8 * i))
+	}
+	page = append(page, granuleBytes...)
+
+	serialBytes := make([]byte, 4)
+	for i := 0; i < 4; i++ {
+		serialBytes[i] = byte(serial >> (8 * i))
+	}
+	page = append(page, serialBytes...)
+
+	indexBytes := make([]byte, 4)
+	for i := 0; i < 4; i++ {
+		indexBytes[i] = byte(index >> (8 * i))
+	}
+	page = append(page, indexBytes...)
+
+	page = append(page, 0x00, 0x00, 0x00, 0x00) // checksum, unused by these tests
+	page = append(page, byte(len(lacing)))
+	page = append(page, lacing...)
+	page = append(page, payload...)
+
+	return page
+}
+
+// buildPageWithChecksum is buildPage but with a correctly computed
+// CRC-32 stamped into the checksum field, for tests that exercise
+// checksum validation.
+func buildPageWithChecksum(headerType byte, granulePos uint64, serial, index uint32, segments ...[]byte) []byte {
+	page := buildPage(headerType, granulePos, serial, index, segments...)
+	crc := oggCRC32(page)
+	binary.LittleEndian.PutUint32(page[22:26], crc)
+	return page
+}
+
+func idHeaderPayload() []byte {
+	payload := append([]byte(idPageSignature), 1, 2)
+	payload = append(payload, 0x00, 0x00)             // pre-skip
+	payload = append(payload, 0x80, 0xBB, 0x00, 0x00) // sample rate 48000
+	payload = append(payload, 0x00, 0x00)             // output gain
+	payload = append(payload, 0x00)                   // channel map
+	return payload
+}
+
+// commentHeaderPayload builds an OpusTags comment page payload per
+// RFC 7845 section 5.2: magic, vendor string, then length-prefixed
+// "KEY=VALUE" comments.
+func commentHeaderPayload(vendor string, comments ...string) []byte {
+	payload := []byte(commentPageSignature)
+
+	vendorLen := make([]byte, 4)
+	binary.LittleEndian.PutUint32(vendorLen, uint32(len(vendor)))
+	payload = append(payload, vendorLen...)
+	payload = append(payload, []byte(vendor)...)
+
+	count := make([]byte, 4)
+	binary.LittleEndian.PutUint32(count, uint32(len(comments)))
+	payload = append(payload, count...)
+
+	for _, comment := range comments {
+		commentLen := make([]byte, 4)
+		binary.LittleEndian.PutUint32(commentLen, uint32(len(comment)))
+		payload = append(payload, commentLen...)
+		payload = append(payload, []byte(comment)...)
+	}
+
+	return payload
+}
+
+func validOggFile() []byte {
+	var buf bytes.Buffer
+	buf.Write(buildPage(pageHeaderTypeBeginningOfStream, 0, 1, 0, idHeaderPayload()))
+	buf.Write(buildPage(0x00, 0, 1, 1, commentHeaderPayload("test vendor", "TITLE=Track One", "ARTIST=Someone", "ARTIST=Someone Else")))
+	buf.Write(buildPage(0x00, 960, 1, 2, []byte{0xAA, 0xBB, 0xCC}))
+	return buf.Bytes()
+}
+
+func TestOggReader_ParseValidHeader(t *testing.T) {
+	reader, header, _, err := NewWith(bytes.NewReader(validOggFile()))
+	if err != nil {
+		t.Fatalf("NewWith returned error: %v", err)
+	}
+
+	if header.Channels != 2 {
+		t.Errorf("expected 2 channels, got %d", header.Channels)
+	}
+	if header.SampleRate != 48000 {
+		t.Errorf("expected 48000 sample rate, got %d", header.SampleRate)
+	}
+
+	payload, pageHeader, err := reader.ParseNextPage()
+	if err != nil {
+		t.Fatalf("ParseNextPage returned error: %v", err)
+	}
+	if !bytes.Equal(payload, []byte{0xAA, 0xBB, 0xCC}) {
+		t.Errorf("unexpected data page payload: %v", payload)
+	}
+	if pageHeader.granulePos != 960 {
+		t.Errorf("unexpected granulePos: %d", pageHeader.granulePos)
+	}
+}
+
+func TestOggReader_ParseNextPage_MultipleSegments(t *testing.T) {
+	first := bytes.Repeat([]byte{0x01}, maxSegmentSize)
+	second := []byte{0x02, 0x03}
+
+	var buf bytes.Buffer
+	buf.Write(buildPage(pageHeaderTypeBeginningOfStream, 0, 1, 0, idHeaderPayload()))
+	buf.Write(buildPage(0x00, 0, 1, 1, commentHeaderPayload("vendor")))
+	buf.Write(buildPage(0x00, 960, 1, 2, first, second))
+
+	reader, _, _, err := NewWith(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewWith returned error: %v", err)
+	}
+
+	payload, _, err := reader.ParseNextPage()
+	if err != nil {
+		t.Fatalf("ParseNextPage returned error: %v", err)
+	}
+
+	expected := append(append([]byte{}, first...), second...)
+	if !bytes.Equal(payload, expected) {
+		t.Errorf("page with multiple segments was not fully read, got %d bytes want %d", len(payload), len(expected))
+	}
+}
+
+func TestOggReader_ParseNextPacket_ContinuationAcrossPages(t *testing.T) {
+	packet := bytes.Repeat([]byte{0x07}, maxSegmentSize+10)
+
+	var buf bytes.Buffer
+	buf.Write(buildPage(pageHeaderTypeBeginningOfStream, 0, 1, 0, idHeaderPayload()))
+	buf.Write(buildPage(0x00, 0, 1, 1, commentHeaderPayload("vendor")))
+	buf.Write(buildPage(0x00, 0, 1, 2, packet[:maxSegmentSize]))
+	buf.Write(buildPage(pageHeaderTypeContinuationOfStream, 960, 1, 3, packet[maxSegmentSize:]))
+
+	reader, _, _, err := NewWith(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewWith returned error: %v", err)
+	}
+
+	got, pageHeader, err := reader.ParseNextPacket()
+	if err != nil {
+		t.Fatalf("ParseNextPacket returned error: %v", err)
+	}
+	if !bytes.Equal(got, packet) {
+		t.Errorf("packet was not reassembled across pages, got %d bytes want %d", len(got), len(packet))
+	}
+	if pageHeader.granulePos != 960 {
+		t.Errorf("unexpected granulePos on reassembled packet: %d", pageHeader.granulePos)
+	}
+}
+
+func TestOggReader_ParseNextPacket_SkipsEmptyPage(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(buildPage(pageHeaderTypeBeginningOfStream, 0, 1, 0, idHeaderPayload()))
+	buf.Write(buildPage(0x00, 0, 1, 1, commentHeaderPayload("vendor")))
+	buf.Write(buildPage(0x00, 0, 1, 2)) // zero-segment page, legal per RFC 3533
+	buf.Write(buildPage(0x00, 960, 1, 3, []byte{0xAA}))
+
+	reader, _, _, err := NewWith(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewWith returned error: %v", err)
+	}
+
+	got, pageHeader, err := reader.ParseNextPacket()
+	if err != nil {
+		t.Fatalf("ParseNextPacket returned error: %v", err)
+	}
+	if !bytes.Equal(got, []byte{0xAA}) {
+		t.Errorf("unexpected packet, got %v", got)
+	}
+	if pageHeader.granulePos != 960 {
+		t.Errorf("unexpected granulePos: %d", pageHeader.granulePos)
+	}
+}
+
+func TestOggReader_NilStream(t *testing.T) {
+	if _, _, _, err := NewWith(nil); err == nil {
+		t.Error("expected error on nil stream")
+	}
+}
+
+func validOggFileWithChecksum() []byte {
+	var buf bytes.Buffer
+	buf.Write(buildPageWithChecksum(pageHeaderTypeBeginningOfStream, 0, 1, 0, idHeaderPayload()))
+	buf.Write(buildPageWithChecksum(0x00, 0, 1, 1, commentHeaderPayload("vendor")))
+	buf.Write(buildPageWithChecksum(0x00, 960, 1, 2, []byte{0xAA, 0xBB, 0xCC}))
+	return buf.Bytes()
+}
+
+func TestOggReader_ChecksumStrict_Valid(t *testing.T) {
+	reader, _, _, err := NewWith(bytes.NewReader(validOggFileWithChecksum()), WithValidationMode(ValidationModeStrict))
+	if err != nil {
+		t.Fatalf("NewWith returned error: %v", err)
+	}
+
+	if _, _, err = reader.ParseNextPage(); err != nil {
+		t.Fatalf("ParseNextPage returned error for a page with a valid checksum: %v", err)
+	}
+}
+
+func TestOggReader_ChecksumStrict_Mutated(t *testing.T) {
+	data := validOggFileWithChecksum()
+	data[len(data)-1] ^= 0xFF // corrupt a payload byte in the final page
+
+	reader, _, _, err := NewWith(bytes.NewReader(data), WithValidationMode(ValidationModeStrict))
+	if err != nil {
+		t.Fatalf("NewWith returned error: %v", err)
+	}
+
+	_, _, err = reader.ParseNextPage()
+	checksumErr, ok := err.(*ChecksumError)
+	if !ok {
+		t.Fatalf("expected *ChecksumError, got %T: %v", err, err)
+	}
+	if checksumErr.PageIndex != 2 {
+		t.Errorf("unexpected PageIndex: %d", checksumErr.PageIndex)
+	}
+}
+
+func TestOggReader_ChecksumWarn_InvokesHandler(t *testing.T) {
+	data := validOggFileWithChecksum()
+	data[len(data)-1] ^= 0xFF
+
+	var reported *ChecksumError
+	reader, _, _, err := NewWith(
+		bytes.NewReader(data),
+		WithValidationMode(ValidationModeWarn),
+		WithChecksumErrorHandler(func(e *ChecksumError) { reported = e }),
+	)
+	if err != nil {
+		t.Fatalf("NewWith returned error: %v", err)
+	}
+
+	payload, _, err := reader.ParseNextPage()
+	if err != nil {
+		t.Fatalf("ParseNextPage returned error under ValidationModeWarn: %v", err)
+	}
+	if len(payload) == 0 {
+		t.Error("expected payload to still be returned under ValidationModeWarn")
+	}
+	if reported == nil {
+		t.Error("expected checksum error handler to be invoked")
+	}
+}
+
+func TestOggReader_Resync_FindsCapturePattern(t *testing.T) {
+	garbage := bytes.Repeat([]byte{0xFF}, 37)
+	data := append(garbage, validOggFile()...)
+
+	reader := &OggReader{stream: bytes.NewReader(data)}
+	skipped, err := reader.Resync()
+	if err != nil {
+		t.Fatalf("Resync returned error: %v", err)
+	}
+	if skipped != int64(len(garbage)) {
+		t.Errorf("expected to skip %d bytes, skipped %d", len(garbage), skipped)
+	}
+
+	payload, _, err := reader.ParseNextPage()
+	if err != nil {
+		t.Fatalf("ParseNextPage after Resync returned error: %v", err)
+	}
+	if !bytes.Contains(payload, []byte(idPageSignature)) {
+		t.Errorf("expected ID header page after resync, got %v", payload)
+	}
+}
+
+func TestOggReader_Resync_NoCapturePattern(t *testing.T) {
+	data := bytes.Repeat([]byte{0xFF}, 32)
+
+	reader := &OggReader{stream: bytes.NewReader(data), maxResyncWindow: 8}
+	if _, err := reader.Resync(); err != ErrNoCapturePattern {
+		t.Fatalf("expected ErrNoCapturePattern, got %v", err)
+	}
+}
+
+func TestOggReader_ParseNextPage_AutoResyncOnGarbagePrefix(t *testing.T) {
+	garbage := []byte{0x01, 0x02, 0x03}
+	data := append(append([]byte{}, garbage...), validOggFile()...)
+
+	_, header, _, err := NewWith(bytes.NewReader(data), WithResyncOnInvalidSignature(true))
+	if err != nil {
+		t.Fatalf("NewWith with resync enabled returned error: %v", err)
+	}
+	if header.Channels != 2 {
+		t.Errorf("expected 2 channels, got %d", header.Channels)
+	}
+}
+
+func TestOggReader_ParseNextPage_BadSignatureWithoutResync(t *testing.T) {
+	data := append([]byte{0x01, 0x02, 0x03}, validOggFile()...)
+
+	if _, _, _, err := NewWith(bytes.NewReader(data)); err == nil {
+		t.Error("expected error reading a page with a garbage prefix when resync is disabled")
+	}
+}
+
+func TestOggReader_ParseCommentHeader(t *testing.T) {
+	_, _, commentHeader, err := NewWith(bytes.NewReader(validOggFile()))
+	if err != nil {
+		t.Fatalf("NewWith returned error: %v", err)
+	}
+
+	if commentHeader.Vendor != "test vendor" {
+		t.Errorf("unexpected vendor: %q", commentHeader.Vendor)
+	}
+
+	if got := commentHeader.Comments["TITLE"]; len(got) != 1 || got[0] != "Track One" {
+		t.Errorf("unexpected TITLE comment: %v", got)
+	}
+
+	artists := commentHeader.Comments["ARTIST"]
+	if len(artists) != 2 || artists[0] != "Someone" || artists[1] != "Someone Else" {
+		t.Errorf("expected repeated ARTIST comments to be preserved, got %v", artists)
+	}
+}
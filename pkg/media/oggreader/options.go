@@ -0,0 +1,63 @@
+package oggreader
+
+// ValidationMode controls how OggReader reacts to a page whose CRC-32
+// checksum does not match the checksum recorded in its header.
+type ValidationMode int
+
+const (
+	// ValidationModeSkip never computes or checks page checksums. This
+	// matches the behavior of OggReader before checksum validation was
+	// added and is the default, including for an OggReader built without
+	// NewWith (e.g. a zero-value struct literal).
+	ValidationModeSkip ValidationMode = iota
+
+	// ValidationModeWarn reports a checksum mismatch to the handler
+	// installed with WithChecksumErrorHandler, if any, but otherwise
+	// returns the page as if it had validated successfully.
+	ValidationModeWarn
+
+	// ValidationModeStrict returns a *ChecksumError from ParseNextPage
+	// and ParseNextPacket as soon as a checksum mismatch is found.
+	ValidationModeStrict
+)
+
+// Option configures an OggReader created by NewWith.
+type Option func(o *OggReader)
+
+// WithValidationMode sets how OggReader handles a page checksum
+// mismatch. The default is ValidationModeSkip.
+func WithValidationMode(mode ValidationMode) Option {
+	return func(o *OggReader) {
+		o.validationMode = mode
+	}
+}
+
+// WithChecksumErrorHandler installs a callback invoked whenever a page
+// checksum mismatch is found under ValidationModeWarn. It has no effect
+// under ValidationModeStrict (where the mismatch is returned as an
+// error instead) or ValidationModeSkip (where checksums are never
+// computed).
+func WithChecksumErrorHandler(handler func(*ChecksumError)) Option {
+	return func(o *OggReader) {
+		o.checksumErrorHandler = handler
+	}
+}
+
+// WithResyncOnInvalidSignature makes ParseNextPage and ParseNextPacket
+// call Resync automatically when a page does not begin with the "OggS"
+// capture pattern, instead of immediately returning an error. This is
+// useful when reading from HTTP radio streams or damaged files where
+// the caller has started reading mid-stream or mid-packet.
+func WithResyncOnInvalidSignature(enabled bool) Option {
+	return func(o *OggReader) {
+		o.resyncOnInvalidSignature = enabled
+	}
+}
+
+// WithMaxResyncWindow sets how many bytes Resync will scan before giving
+// up and returning ErrNoCapturePattern. The default is 64KiB.
+func WithMaxResyncWindow(n int) Option {
+	return func(o *OggReader) {
+		o.maxResyncWindow = n
+	}
+}
@@ -0,0 +1,60 @@
+package oggreader
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// defaultMaxResyncWindow bounds how far Resync will scan looking for the
+// next capture pattern before giving up, when no WithMaxResyncWindow
+// option has been supplied.
+const defaultMaxResyncWindow = 64 * 1024
+
+// ErrNoCapturePattern is returned by Resync when no "OggS" capture
+// pattern is found within the configured resync window.
+var ErrNoCapturePattern = errors.New("oggreader: no OggS capture pattern found within resync window")
+
+var pageHeaderSignatureBytes = []byte(pageHeaderSignature)
+
+// Resync scans forward from the current stream position for the next
+// "OggS" capture pattern, leaving the stream positioned at the start of
+// it, and returns the number of bytes that were skipped to get there.
+//
+// This is useful for jumping into an Ogg stream at an arbitrary offset,
+// or recovering after corrupted or truncated page data, rather than
+// failing outright on a bad header signature.
+func (o *OggReader) Resync() (int64, error) {
+	maxWindow := o.maxResyncWindow
+	if maxWindow <= 0 {
+		maxWindow = defaultMaxResyncWindow
+	}
+
+	var window [4]byte
+	filled := 0
+
+	b := make([]byte, 1)
+	var skipped int64
+	for skipped < int64(maxWindow) {
+		if _, err := o.stream.Read(b); err != nil {
+			return skipped, err
+		}
+		skipped++
+
+		if filled < 4 {
+			window[filled] = b[0]
+			filled++
+		} else {
+			window[0], window[1], window[2], window[3] = window[1], window[2], window[3], b[0]
+		}
+
+		if filled == 4 && bytes.Equal(window[:], pageHeaderSignatureBytes) {
+			if _, err := o.stream.Seek(-4, io.SeekCurrent); err != nil {
+				return skipped, err
+			}
+			return skipped - 4, nil
+		}
+	}
+
+	return skipped, ErrNoCapturePattern
+}
@@ -0,0 +1,36 @@
+package oggwriter
+
+// generateChecksumTable builds the lookup table for the Ogg-specific
+// CRC-32: polynomial 0x04c11db7, non-reflected, initial value 0, no
+// final XOR.
+//
+// https://tools.ietf.org/html/rfc3533.html#section-5
+func generateChecksumTable() [256]uint32 {
+	const polynomial = 0x04c11db7
+
+	var table [256]uint32
+	for i := range table {
+		r := uint32(i) << 24
+		for bit := 0; bit < 8; bit++ {
+			if r&0x80000000 != 0 {
+				r = (r << 1) ^ polynomial
+			} else {
+				r <<= 1
+			}
+		}
+		table[i] = r
+	}
+
+	return table
+}
+
+// crc32 computes the Ogg-specific CRC-32 over page, which must have its
+// 4 checksum bytes (at offset 22) already zeroed out.
+func (o *OggWriter) crc32(page []byte) uint32 {
+	var crc uint32
+	for _, b := range page {
+		crc = (crc << 8) ^ o.checksumTable[byte(crc>>24)^b]
+	}
+
+	return crc
+}
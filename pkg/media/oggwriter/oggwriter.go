@@ -0,0 +1,232 @@
+// Package oggwriter implements the Ogg media container writer
+package oggwriter
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+
+	"github.com/pion/rtp"
+)
+
+const (
+	pageHeaderSignature  = "OggS"
+	idPageSignature      = "OpusHead"
+	commentPageSignature = "OpusTags"
+
+	pageHeaderTypeContinuationOfStream = 0x01
+	pageHeaderTypeBeginningOfStream    = 0x02
+	pageHeaderTypeEndOfStream          = 0x04
+
+	pageHeaderLen = 27
+
+	// maxPagePayload is the largest payload a single Ogg page can carry
+	// without splitting: the lacing table has at most 255 entries of at
+	// most maxSegmentSize bytes each.
+	maxPagePayload = maxSegmentSize * maxSegmentSize
+
+	// noPacketCompletedGranulePos is the special granule position (all
+	// bits set) that marks a page on which no packet is completed, i.e.
+	// every page of a split packet except its last.
+	//
+	// https://tools.ietf.org/html/rfc3533.html#section-4
+	noPacketCompletedGranulePos = ^uint64(0)
+
+	// defaultPreSkip matches the value used by libopus/opusenc for a
+	// freshly encoded stream; real encoders are expected to override it
+	// via the ID header, but there is no better default to pick here.
+	defaultPreSkip = 3840
+
+	// maxSegmentSize is the largest a single lacing-table entry (and
+	// thus a single segment of page payload) may be.
+	maxSegmentSize = 255
+
+	// granuleSampleRate is the clock rate granule positions are always
+	// expressed in for Ogg Opus, regardless of the stream's actual
+	// sample rate.
+	//
+	// https://tools.ietf.org/html/rfc7845.html#section-4
+	granuleSampleRate = 48000
+)
+
+// OggWriter is used to take RTP packets containing Opus payloads and
+// write them to an Ogg container
+type OggWriter struct {
+	stream                  io.Writer
+	fd                      *os.File
+	sampleRate              uint32
+	channelCount            uint16
+	serial                  uint32
+	pageIndex               uint32
+	checksumTable           [256]uint32
+	previousGranulePosition uint64
+}
+
+// New builds a new Ogg writer that writes to fileName
+func New(fileName string, sampleRate uint32, channelCount uint16) (*OggWriter, error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	writer, err := NewWith(f, sampleRate, channelCount)
+	if err != nil {
+		return nil, err
+	}
+	writer.fd = f
+
+	return writer, nil
+}
+
+// NewWith initializes a new Ogg writer that writes its output to out
+func NewWith(out io.Writer, sampleRate uint32, channelCount uint16) (*OggWriter, error) {
+	if out == nil {
+		return nil, fmt.Errorf("stream is nil")
+	}
+
+	writer := &OggWriter{
+		stream:        out,
+		sampleRate:    sampleRate,
+		channelCount:  channelCount,
+		serial:        rand.Uint32(), //nolint:gosec // no cryptographic properties required of the page serial
+		checksumTable: generateChecksumTable(),
+	}
+
+	if err := writer.writeHeaders(); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+// writeHeaders writes the two mandatory header pages: the OpusHead ID
+// header and the OpusTags comment header.
+//
+// https://tools.ietf.org/html/rfc7845.html#section-5
+func (o *OggWriter) writeHeaders() error {
+	idHeader := make([]byte, 19)
+	copy(idHeader, idPageSignature)
+	idHeader[8] = 1 // version
+	idHeader[9] = uint8(o.channelCount)
+	binary.LittleEndian.PutUint16(idHeader[10:12], defaultPreSkip)
+	binary.LittleEndian.PutUint32(idHeader[12:16], o.sampleRate)
+	binary.LittleEndian.PutUint16(idHeader[16:18], 0) // output gain
+	idHeader[18] = 0                                  // channel mapping family
+
+	if _, err := o.stream.Write(o.createPage(idHeader, pageHeaderTypeBeginningOfStream, 0, true)); err != nil {
+		return err
+	}
+
+	const vendor = "pion"
+	commentHeader := make([]byte, 0, 16+len(vendor))
+	commentHeader = append(commentHeader, []byte(commentPageSignature)...)
+	vendorLen := make([]byte, 4)
+	binary.LittleEndian.PutUint32(vendorLen, uint32(len(vendor)))
+	commentHeader = append(commentHeader, vendorLen...)
+	commentHeader = append(commentHeader, []byte(vendor)...)
+	commentHeader = append(commentHeader, 0x00, 0x00, 0x00, 0x00) // zero user comments
+
+	_, err := o.stream.Write(o.createPage(commentHeader, 0, 0, true))
+	return err
+}
+
+// WriteRTP takes a RTP packet containing an Opus payload, and writes it
+// as one or more new Ogg data pages (see writeDataPage).
+func (o *OggWriter) WriteRTP(packet *rtp.Packet) error {
+	if o.stream == nil {
+		return fmt.Errorf("file not opened")
+	}
+
+	if len(packet.Payload) == 0 {
+		return nil
+	}
+
+	samples, err := opusPacketSamples(packet.Payload)
+	if err != nil {
+		return err
+	}
+
+	o.previousGranulePosition += samples
+
+	return o.writeDataPage(packet.Payload, o.previousGranulePosition)
+}
+
+// Close stops the writer, flushing a final EOS-flagged page, and closes
+// the underlying file if one was opened with New.
+func (o *OggWriter) Close() error {
+	if o.stream == nil {
+		return nil
+	}
+	defer func() { o.stream = nil }()
+
+	if _, err := o.stream.Write(o.createPage(nil, pageHeaderTypeEndOfStream, o.previousGranulePosition, true)); err != nil {
+		return err
+	}
+
+	if o.fd == nil {
+		return nil
+	}
+	return o.fd.Close()
+}
+
+// writeDataPage writes payload as one or more Ogg pages, splitting it on
+// maxPagePayload boundaries and flagging every page after the first as
+// a continuation of the packet. Only the final page, which is the only
+// one to actually complete the packet, carries granulePos; every page
+// before it carries noPacketCompletedGranulePos instead.
+func (o *OggWriter) writeDataPage(payload []byte, granulePos uint64) error {
+	headerType := uint8(0)
+
+	for len(payload) >= maxPagePayload {
+		if _, err := o.stream.Write(o.createPage(payload[:maxPagePayload], headerType, noPacketCompletedGranulePos, false)); err != nil {
+			return err
+		}
+		payload = payload[maxPagePayload:]
+		headerType = pageHeaderTypeContinuationOfStream
+	}
+
+	_, err := o.stream.Write(o.createPage(payload, headerType, granulePos, true))
+	return err
+}
+
+// createPage lays payload out as one Ogg page: a sequence of up to
+// 255-byte lacing segments, followed by the page's CRC-32 checksum. If
+// terminatesPacket is true, the page's final segment is a segment
+// shorter than 255 bytes (or a single 0-length segment for an empty
+// payload) marking the end of the packet; otherwise payload must be an
+// exact multiple of maxSegmentSize and every segment is full-sized,
+// signaling that the packet continues onto the next page.
+func (o *OggWriter) createPage(payload []byte, headerType uint8, granulePos uint64, terminatesPacket bool) []byte {
+	fullSegments := len(payload) / maxSegmentSize
+	segmentCount := fullSegments
+	if terminatesPacket {
+		segmentCount++
+	}
+
+	page := make([]byte, pageHeaderLen+segmentCount+len(payload))
+
+	copy(page[0:4], pageHeaderSignature)
+	page[4] = 0 // version
+	page[5] = headerType
+	binary.LittleEndian.PutUint64(page[6:14], granulePos)
+	binary.LittleEndian.PutUint32(page[14:18], o.serial)
+	binary.LittleEndian.PutUint32(page[18:22], o.pageIndex)
+	o.pageIndex++
+	page[26] = uint8(segmentCount)
+
+	for i := 0; i < fullSegments; i++ {
+		page[pageHeaderLen+i] = maxSegmentSize
+	}
+	if terminatesPacket {
+		page[pageHeaderLen+fullSegments] = uint8(len(payload) % maxSegmentSize)
+	}
+
+	copy(page[pageHeaderLen+segmentCount:], payload)
+
+	checksum := o.crc32(page)
+	binary.LittleEndian.PutUint32(page[22:26], checksum)
+
+	return page
+}
@@ -0,0 +1,245 @@
+package oggwriter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/pion/rtp"
+)
+
+// readPage reads one page from r and returns its header fields along
+// with its payload, or ok=false if r is exhausted.
+func readPage(t *testing.T, r *bytes.Reader) (headerType byte, granulePos uint64, index uint32, payload []byte, ok bool) {
+	t.Helper()
+
+	if r.Len() == 0 {
+		return 0, 0, 0, nil, false
+	}
+
+	h := make([]byte, pageHeaderLen)
+	if _, err := r.Read(h); err != nil {
+		t.Fatalf("failed to read page header: %v", err)
+	}
+	if string(h[0:4]) != pageHeaderSignature {
+		t.Fatalf("bad page signature: %q", h[0:4])
+	}
+
+	headerType = h[5]
+	granulePos = binary.LittleEndian.Uint64(h[6:14])
+	index = binary.LittleEndian.Uint32(h[18:22])
+	segmentCount := int(h[26])
+
+	lacing := make([]byte, segmentCount)
+	if _, err := r.Read(lacing); err != nil {
+		t.Fatalf("failed to read lacing table: %v", err)
+	}
+
+	payloadSize := 0
+	for _, s := range lacing {
+		payloadSize += int(s)
+	}
+
+	payload = make([]byte, payloadSize)
+	if payloadSize > 0 {
+		if _, err := r.Read(payload); err != nil {
+			t.Fatalf("failed to read page payload: %v", err)
+		}
+	}
+
+	return headerType, granulePos, index, payload, true
+}
+
+func TestOggWriter_WriteHeaders(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer, err := NewWith(&buf, 48000, 2)
+	if err != nil {
+		t.Fatalf("NewWith returned error: %v", err)
+	}
+	if writer.serial == 0 {
+		t.Error("expected a non-zero random serial")
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+
+	headerType, _, index, payload, ok := readPage(t, r)
+	if !ok {
+		t.Fatal("expected an ID header page")
+	}
+	if headerType != pageHeaderTypeBeginningOfStream {
+		t.Errorf("expected BOS header type, got 0x%02x", headerType)
+	}
+	if index != 0 {
+		t.Errorf("expected ID header to be page 0, got %d", index)
+	}
+	if string(payload[:8]) != idPageSignature {
+		t.Errorf("unexpected ID header signature: %q", payload[:8])
+	}
+	if payload[9] != 2 {
+		t.Errorf("expected 2 channels in ID header, got %d", payload[9])
+	}
+
+	_, _, index, payload, ok = readPage(t, r)
+	if !ok {
+		t.Fatal("expected a comment header page")
+	}
+	if index != 1 {
+		t.Errorf("expected comment header to be page 1, got %d", index)
+	}
+	if string(payload[:8]) != commentPageSignature {
+		t.Errorf("unexpected comment header signature: %q", payload[:8])
+	}
+}
+
+func TestOggWriter_WriteRTP(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer, err := NewWith(&buf, 48000, 2)
+	if err != nil {
+		t.Fatalf("NewWith returned error: %v", err)
+	}
+
+	// config 23 (CELT-only WB, 20ms), code 0 (1 frame) -> TOC byte 0xB8
+	opusPayload := []byte{0xB8, 0x01, 0x02, 0x03}
+	if err = writer.WriteRTP(&rtp.Packet{Payload: opusPayload}); err != nil {
+		t.Fatalf("WriteRTP returned error: %v", err)
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	if _, _, _, _, ok := readPage(t, r); !ok { // ID header
+		t.Fatal("expected an ID header page")
+	}
+	if _, _, _, _, ok := readPage(t, r); !ok { // comment header
+		t.Fatal("expected a comment header page")
+	}
+
+	headerType, granulePos, index, payload, ok := readPage(t, r)
+	if !ok {
+		t.Fatal("expected a data page")
+	}
+	if headerType != 0 {
+		t.Errorf("expected a plain header type for a single-page packet, got 0x%02x", headerType)
+	}
+	if index != 2 {
+		t.Errorf("expected the data page to be page 2, got %d", index)
+	}
+	if !bytes.Equal(payload, opusPayload) {
+		t.Errorf("unexpected data page payload: %v", payload)
+	}
+	if granulePos != 960 { // 20ms @ 48kHz
+		t.Errorf("expected granulePos 960, got %d", granulePos)
+	}
+}
+
+func TestOggWriter_WriteRTP_MultiSegmentPage(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer, err := NewWith(&buf, 48000, 2)
+	if err != nil {
+		t.Fatalf("NewWith returned error: %v", err)
+	}
+
+	opusPayload := append([]byte{0xB8}, bytes.Repeat([]byte{0x11}, maxSegmentSize+10)...)
+	if err = writer.WriteRTP(&rtp.Packet{Payload: opusPayload}); err != nil {
+		t.Fatalf("WriteRTP returned error: %v", err)
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	readPage(t, r) // ID header
+	readPage(t, r) // comment header
+
+	_, _, _, payload, ok := readPage(t, r)
+	if !ok {
+		t.Fatal("expected a data page")
+	}
+	if !bytes.Equal(payload, opusPayload) {
+		t.Errorf("payload spanning multiple segments was not written correctly, got %d bytes want %d", len(payload), len(opusPayload))
+	}
+}
+
+func TestOggWriter_WriteRTP_FlushesOnPageBoundary(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer, err := NewWith(&buf, 48000, 2)
+	if err != nil {
+		t.Fatalf("NewWith returned error: %v", err)
+	}
+
+	opusPayload := append([]byte{0xB8}, bytes.Repeat([]byte{0x22}, maxPagePayload)...)
+	if err = writer.WriteRTP(&rtp.Packet{Payload: opusPayload}); err != nil {
+		t.Fatalf("WriteRTP returned error: %v", err)
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	readPage(t, r) // ID header
+	readPage(t, r) // comment header
+
+	headerType, granulePos, _, payload, ok := readPage(t, r)
+	if !ok {
+		t.Fatal("expected the first physical page of the split packet")
+	}
+	if headerType != 0 {
+		t.Errorf("expected the first page to carry a plain header type, got 0x%02x", headerType)
+	}
+	if granulePos != noPacketCompletedGranulePos {
+		t.Errorf("expected the first page to complete no packet, got granulePos %d", granulePos)
+	}
+	if len(payload) != maxPagePayload {
+		t.Errorf("expected the first page to be exactly maxPagePayload bytes, got %d", len(payload))
+	}
+
+	headerType, granulePos, _, payload, ok = readPage(t, r)
+	if !ok {
+		t.Fatal("expected the second (final) physical page of the split packet")
+	}
+	if headerType != pageHeaderTypeContinuationOfStream {
+		t.Errorf("expected the final page to carry the continuation header type, got 0x%02x", headerType)
+	}
+	if granulePos != 960 {
+		t.Errorf("expected the final page to carry the real granulePos, got %d", granulePos)
+	}
+
+	wantTail := opusPayload[maxPagePayload:]
+	if !bytes.Equal(payload, wantTail) {
+		t.Errorf("unexpected tail payload on the final page, got %d bytes want %d", len(payload), len(wantTail))
+	}
+
+	if _, _, _, _, ok = readPage(t, r); ok {
+		t.Error("expected no further pages for this packet")
+	}
+}
+
+func TestOggWriter_Close(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer, err := NewWith(&buf, 48000, 2)
+	if err != nil {
+		t.Fatalf("NewWith returned error: %v", err)
+	}
+	if err = writer.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	r := bytes.NewReader(buf.Bytes())
+	readPage(t, r) // ID header
+	readPage(t, r) // comment header
+
+	headerType, _, _, _, ok := readPage(t, r)
+	if !ok {
+		t.Fatal("expected an EOS page")
+	}
+	if headerType != pageHeaderTypeEndOfStream {
+		t.Errorf("expected EOS header type, got 0x%02x", headerType)
+	}
+
+	if err = writer.Close(); err != nil {
+		t.Errorf("Close should be a no-op after the stream is already closed: %v", err)
+	}
+}
+
+func TestOggWriter_NilStream(t *testing.T) {
+	if _, err := NewWith(nil, 48000, 2); err == nil {
+		t.Error("expected error on nil stream")
+	}
+}
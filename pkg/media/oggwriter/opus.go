@@ -0,0 +1,54 @@
+package oggwriter
+
+import "fmt"
+
+// opusFrameDurationsMs is indexed by the 5-bit "config" field of an
+// Opus TOC byte and gives the duration, in milliseconds, of a single
+// frame encoded with that configuration.
+//
+// https://tools.ietf.org/html/rfc6716#section-3.1
+var opusFrameDurationsMs = [32]float64{
+	10, 20, 40, 60, // SILK-only NB
+	10, 20, 40, 60, // SILK-only MB
+	10, 20, 40, 60, // SILK-only WB
+	10, 20, // Hybrid SWB
+	10, 20, // Hybrid FB
+	2.5, 5, 10, 20, // CELT-only NB
+	2.5, 5, 10, 20, // CELT-only WB
+	2.5, 5, 10, 20, // CELT-only SWB
+	2.5, 5, 10, 20, // CELT-only FB
+}
+
+// opusPacketSamples returns the number of 48kHz samples represented by
+// an Opus packet, derived from its TOC byte: the frame duration implied
+// by the config field, multiplied by the number of frames the packet
+// carries.
+//
+// https://tools.ietf.org/html/rfc6716#section-3.1
+func opusPacketSamples(payload []byte) (uint64, error) {
+	if len(payload) == 0 {
+		return 0, fmt.Errorf("opus packet is empty")
+	}
+
+	toc := payload[0]
+	config := toc >> 3
+	frameDurationMs := opusFrameDurationsMs[config]
+
+	var frameCount int
+	switch toc & 0x03 {
+	case 0:
+		frameCount = 1
+	case 1, 2:
+		frameCount = 2
+	default: // code 3: arbitrary number of frames
+		if len(payload) < 2 {
+			return 0, fmt.Errorf("opus packet is too short for a code 3 frame count byte")
+		}
+		frameCount = int(payload[1] & 0x3F)
+		if frameCount == 0 {
+			return 0, fmt.Errorf("opus packet declares zero frames")
+		}
+	}
+
+	return uint64(frameDurationMs * float64(frameCount) * granuleSampleRate / 1000), nil
+}